@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordSpec declaratively describes one DNS record this tool should
+// manage, read from the --config file. It generalizes the previous
+// hard-coded "A record + wildcard" behavior to arbitrary record types and
+// content sources.
+type RecordSpec struct {
+	Type    string `yaml:"type" json:"type"`       // A, AAAA, CNAME, TXT, ...
+	Name    string `yaml:"name" json:"name"`       // e.g. "example.com" or "*.example.com"
+	TTL     int    `yaml:"ttl" json:"ttl"`         // 1 means "automatic" in Cloudflare's API
+	Proxied bool   `yaml:"proxied" json:"proxied"` // whether Cloudflare should proxy the record
+	Content string `yaml:"content" json:"content"` // "public-ipv4", "public-ipv6", "literal:<value>", or "interface:<name>"
+}
+
+// Config is the top-level shape of a --config records.yaml/json file.
+type Config struct {
+	Records []RecordSpec `yaml:"records" json:"records"`
+}
+
+// loadConfig reads and parses a records config file, choosing a JSON or
+// YAML decoder based on its extension.
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	var cfg Config
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing JSON config %s: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing YAML config %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config extension %q (want .yaml, .yml or .json)", ext)
+	}
+
+	for i, rec := range cfg.Records {
+		if rec.Type == "" || rec.Name == "" || rec.Content == "" {
+			return nil, fmt.Errorf("record %d in %s is missing a required field (type, name, content)", i, path)
+		}
+		if rec.TTL == 0 {
+			cfg.Records[i].TTL = 1 // Cloudflare's "automatic" TTL
+		}
+	}
+
+	return &cfg, nil
+}
+
+// resolveContent turns a RecordSpec's content source into the literal
+// value to write to Cloudflare. The `interface:` source picks an IPv4 or
+// IPv6 address from the interface depending on r.Type, so an AAAA record
+// doesn't end up with an IPv4 address Cloudflare would reject.
+func (r RecordSpec) resolveContent() (string, error) {
+	switch {
+	case r.Content == "public-ipv4":
+		return fetchPublicIPv4()
+	case r.Content == "public-ipv6":
+		return fetchPublicIPv6()
+	case strings.HasPrefix(r.Content, "literal:"):
+		return strings.TrimPrefix(r.Content, "literal:"), nil
+	case strings.HasPrefix(r.Content, "interface:"):
+		name := strings.TrimPrefix(r.Content, "interface:")
+		if strings.EqualFold(r.Type, "AAAA") {
+			return interfaceIPv6(name)
+		}
+		return interfaceIPv4(name)
+	default:
+		return "", fmt.Errorf("unrecognized content source %q for record %s %s", r.Content, r.Type, r.Name)
+	}
+}
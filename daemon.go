@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// ipv4DaemonProviders are tried in order until one succeeds, so a single
+// provider being down or rate-limiting doesn't stall the daemon.
+var ipv4DaemonProviders = []string{
+	"http://ipinfo.io/ip",
+	"https://ifconfig.co/ip",
+	"https://api.ipify.org",
+	"https://1.1.1.1/cdn-cgi/trace",
+}
+
+var ipv6DaemonProviders = []string{
+	"https://api6.ipify.org",
+	"https://ipv6.icanhazip.com",
+}
+
+// runDaemon polls for the public IP every interval, only touching
+// Cloudflare when it actually changes since the last run (tracked via
+// cachePath so a restart doesn't re-trigger updates), and reacts to
+// SIGHUP by checking immediately. It uses a retrying HTTP client with
+// exponential backoff and jitter for both IP lookups and the Cloudflare
+// API, since both run unattended and should ride out transient failures.
+func runDaemon(interval time.Duration, cachePath string) {
+	retryClient := retryablehttp.NewClient()
+	retryClient.Logger = nil
+	// DefaultBackoff is exponential but deterministic; add up to 25% random
+	// jitter on top so many daemons don't retry in lockstep.
+	retryClient.Backoff = func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		d := retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+		if d <= 0 {
+			return d
+		}
+		return d + time.Duration(rand.Int63n(int64(d)/4+1))
+	}
+	httpClient := retryClient.StandardClient()
+
+	api, err := newCloudflareClient(httpClient)
+	if err != nil {
+		log.Fatalf("daemon: failed to create Cloudflare API client: %v", err)
+	}
+	domains := parseDomains()
+	wantIPv4, wantIPv6 := ipFamilies()
+
+	lastIPv4, lastIPv6 := readIPCache(cachePath)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	check := func() {
+		// currentIPv4/currentIPv6 default to the last-known value, so a
+		// transient lookup failure this cycle neither looks like a change
+		// (and fires a false ip_changed/update) nor overwrites the cache
+		// with an empty value that would itself look like a change on the
+		// next successful lookup.
+		currentIPv4, currentIPv6 := lastIPv4, lastIPv6
+		if wantIPv4 {
+			if ip, err := fetchPublicIPViaProviders(httpClient, ipv4DaemonProviders); err != nil {
+				log.Printf("daemon: could not determine public IPv4 address, keeping last-known %q: %v", lastIPv4, err)
+			} else {
+				currentIPv4 = ip
+			}
+		}
+		if wantIPv6 {
+			if ip, err := fetchPublicIPViaProviders(httpClient, ipv6DaemonProviders); err != nil {
+				log.Printf("daemon: could not determine public IPv6 address, keeping last-known %q: %v", lastIPv6, err)
+			} else {
+				currentIPv6 = ip
+			}
+		}
+
+		if currentIPv4 == lastIPv4 && currentIPv6 == lastIPv6 {
+			log.Printf("daemon: IP unchanged (v4=%s v6=%s), skipping Cloudflare", currentIPv4, currentIPv6)
+			return
+		}
+
+		log.Printf("daemon: IP changed (v4=%s v6=%s), updating %d domain(s)", currentIPv4, currentIPv6, len(domains))
+		notify("ip_changed", fmt.Sprintf("Public IP changed to v4=%s v6=%s", currentIPv4, currentIPv6))
+
+		var hadError bool
+		for _, domain := range domains {
+			if err := updateDomain(context.Background(), api, currentIPv4, currentIPv6, domain); err != nil {
+				hadError = true
+				log.Printf("daemon: error updating %s: %v", domain, err)
+				notify("error", fmt.Sprintf("Error updating %s: %v", domain, err))
+			}
+		}
+		if hadError {
+			return
+		}
+
+		lastIPv4, lastIPv6 = currentIPv4, currentIPv6
+		if err := writeIPCache(cachePath, lastIPv4, lastIPv6); err != nil {
+			log.Printf("daemon: failed to write IP cache %s: %v", cachePath, err)
+		}
+	}
+
+	check()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-sighup:
+			log.Print("daemon: received SIGHUP, checking immediately")
+			check()
+		}
+	}
+}
+
+// defaultIPCachePath returns CACHE_FILE if set, otherwise a path under the
+// OS temp directory so unattended systemd units have a writable default.
+func defaultIPCachePath() string {
+	if path := os.Getenv("CACHE_FILE"); path != "" {
+		return path
+	}
+	return os.TempDir() + "/cloudflare-ddns-renewal.cache"
+}
+
+// fetchPublicIPViaProviders tries each provider URL in order, returning the
+// first one that yields a usable response.
+func fetchPublicIPViaProviders(client *http.Client, providers []string) (string, error) {
+	var lastErr error
+	for _, url := range providers {
+		resp, err := client.Get(url)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", url, err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", url, err)
+			continue
+		}
+
+		ip := strings.TrimSpace(string(body))
+		if strings.Contains(url, "cdn-cgi/trace") {
+			ip = parseTraceIP(ip)
+		}
+		if ip == "" {
+			lastErr = fmt.Errorf("%s: empty response", url)
+			continue
+		}
+		return ip, nil
+	}
+	return "", fmt.Errorf("all IP providers failed, last error: %w", lastErr)
+}
+
+// parseTraceIP extracts the "ip=" field from a Cloudflare /cdn-cgi/trace
+// response body.
+func parseTraceIP(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(line, "ip="); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// readIPCache loads the last-seen IPs from disk so a daemon restart
+// doesn't treat an unchanged IP as a fresh change and spam Cloudflare.
+// Any read error is treated as "no cache yet".
+func readIPCache(path string) (ipv4, ipv6 string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "v4="); ok {
+			ipv4 = strings.TrimSpace(v)
+		}
+		if v, ok := strings.CutPrefix(line, "v6="); ok {
+			ipv6 = strings.TrimSpace(v)
+		}
+	}
+	return ipv4, ipv6
+}
+
+func writeIPCache(path, ipv4, ipv6 string) error {
+	content := fmt.Sprintf("v4=%s\nv6=%s\n", ipv4, ipv6)
+	return ioutil.WriteFile(path, []byte(content), 0o644)
+}
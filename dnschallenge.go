@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// Default knobs for the ACME DNS-01 solver, overridable via env vars so the
+// same binary behaves well both against Cloudflare's normal propagation
+// speed and in slower/test environments.
+const (
+	defaultTTL                = 120
+	defaultPropagationTimeout = 2 * time.Minute
+	defaultPollingInterval    = 2 * time.Second
+	defaultHTTPTimeout        = 30 * time.Second
+)
+
+// dnsProvider is a lego-style ACME DNS-01 solver backed by Cloudflare, so
+// this tool can also issue certificates for the same domains it keeps
+// A/AAAA records in sync for.
+type dnsProvider struct {
+	api *cloudflare.API
+
+	ttl                int
+	propagationTimeout time.Duration
+	pollingInterval    time.Duration
+	httpTimeout        time.Duration
+
+	mu        sync.Mutex
+	recordIDs map[string]string // fqdn|value -> Cloudflare DNS record ID
+}
+
+// newDNSProvider builds a dnsProvider from the environment, reusing the same
+// Cloudflare credential resolution as the regular DDNS update path.
+func newDNSProvider() (*dnsProvider, error) {
+	api, err := newCloudflareClient(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dnsProvider{
+		api:                api,
+		ttl:                envInt("CLOUDFLARE_TTL", defaultTTL),
+		propagationTimeout: envDuration("CLOUDFLARE_PROPAGATION_TIMEOUT", defaultPropagationTimeout),
+		pollingInterval:    envDuration("CLOUDFLARE_POLLING_INTERVAL", defaultPollingInterval),
+		httpTimeout:        envDuration("CLOUDFLARE_HTTP_TIMEOUT", defaultHTTPTimeout),
+		recordIDs:          make(map[string]string),
+	}, nil
+}
+
+// Present creates the TXT record required to fulfil an ACME DNS-01
+// challenge and waits for it to propagate to the zone's authoritative
+// nameservers.
+func (d *dnsProvider) Present(domain, token, keyAuth string) error {
+	fqdn, value := challengeFQDN(domain), challengeValue(keyAuth)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.httpTimeout)
+	defer cancel()
+
+	zoneID, err := zoneIDForName(d.api, fqdn)
+	if err != nil {
+		return fmt.Errorf("dnschallenge: could not find zone for %s: %w", fqdn, err)
+	}
+	rc := &cloudflare.ResourceContainer{Identifier: zoneID, Type: "zone"}
+
+	rec, err := d.api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    fqdn,
+		Content: value,
+		TTL:     d.ttl,
+	})
+	if err != nil {
+		return fmt.Errorf("dnschallenge: failed to create TXT record for %s: %w", fqdn, err)
+	}
+
+	d.mu.Lock()
+	d.recordIDs[recordKey(fqdn, value)] = rec.ID
+	d.mu.Unlock()
+
+	return d.waitForPropagation(fqdn, value)
+}
+
+// CleanUp removes the TXT record created by Present. It first checks the
+// in-process recordIDs map (the fast path for a lego provider that calls
+// Present and CleanUp within the same process), then falls back to
+// looking the record up via the API by name and content — this is the
+// only path available to the `dnschallenge cleanup` subcommand, since it
+// runs as a separate process from `dnschallenge present` and has no
+// access to the other invocation's in-memory state.
+func (d *dnsProvider) CleanUp(domain, token, keyAuth string) error {
+	fqdn, value := challengeFQDN(domain), challengeValue(keyAuth)
+	key := recordKey(fqdn, value)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.httpTimeout)
+	defer cancel()
+
+	zoneID, err := zoneIDForName(d.api, fqdn)
+	if err != nil {
+		return fmt.Errorf("dnschallenge: could not find zone for %s: %w", fqdn, err)
+	}
+	rc := &cloudflare.ResourceContainer{Identifier: zoneID, Type: "zone"}
+
+	d.mu.Lock()
+	recordID, ok := d.recordIDs[key]
+	if ok {
+		delete(d.recordIDs, key)
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		records, _, err := d.api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: "TXT", Name: fqdn, Content: value})
+		if err != nil {
+			return fmt.Errorf("dnschallenge: failed to look up TXT record for %s: %w", fqdn, err)
+		}
+		if len(records) == 0 {
+			return fmt.Errorf("dnschallenge: no TXT record found for %s with the expected value, nothing to clean up", fqdn)
+		}
+		recordID = records[0].ID
+	}
+
+	if err := d.api.DeleteDNSRecord(ctx, rc, recordID); err != nil {
+		return fmt.Errorf("dnschallenge: failed to delete TXT record for %s: %w", fqdn, err)
+	}
+	return nil
+}
+
+// waitForPropagation polls the zone's authoritative nameservers directly
+// (bypassing recursive resolver caches) until the TXT record is visible or
+// the propagation timeout elapses.
+func (d *dnsProvider) waitForPropagation(fqdn, value string) error {
+	deadline := time.Now().Add(d.propagationTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if ok, err := txtRecordPropagated(fqdn, value); err != nil {
+			lastErr = err
+		} else if ok {
+			return nil
+		}
+		time.Sleep(d.pollingInterval)
+	}
+	if lastErr != nil {
+		return fmt.Errorf("dnschallenge: timed out waiting for %s to propagate: %w", fqdn, lastErr)
+	}
+	return fmt.Errorf("dnschallenge: timed out waiting for %s to propagate", fqdn)
+}
+
+// txtRecordPropagated queries every authoritative nameserver for the zone
+// containing fqdn and reports whether all of them already serve value.
+func txtRecordPropagated(fqdn, value string) (bool, error) {
+	zone, err := authoritativeZone(fqdn)
+	if err != nil {
+		return false, err
+	}
+	nameservers, err := net.LookupNS(zone)
+	if err != nil {
+		return false, fmt.Errorf("looking up nameservers for %s: %w", zone, err)
+	}
+	if len(nameservers) == 0 {
+		return false, fmt.Errorf("no nameservers found for %s", zone)
+	}
+
+	for _, ns := range nameservers {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, net.JoinHostPort(strings.TrimSuffix(ns.Host, "."), "53"))
+			},
+		}
+		txts, err := resolver.LookupTXT(context.Background(), fqdn)
+		if err != nil {
+			return false, nil
+		}
+		found := false
+		for _, txt := range txts {
+			if txt == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// authoritativeZone finds the zone delegated to host fqdn's NS records by
+// walking up its label chain (the same approach zoneIDForName uses to find
+// the owning Cloudflare zone), since a non-apex challenge name like
+// "_acme-challenge.sub.example.com" is almost never itself delegated and
+// just stripping the "_acme-challenge." prefix would often land on a label
+// with no NS records at all.
+func authoritativeZone(fqdn string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if nameservers, err := net.LookupNS(candidate); err == nil && len(nameservers) > 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no delegated zone found for %s or any of its parent domains", fqdn)
+}
+
+// challengeFQDN computes the DNS-01 challenge record name for domain.
+func challengeFQDN(domain string) string {
+	return "_acme-challenge." + strings.TrimSuffix(domain, ".") + "."
+}
+
+// challengeValue derives the TXT record content from the ACME key
+// authorization: base64url(SHA-256(keyAuth)), no padding, per RFC 8555 §8.4.
+func challengeValue(keyAuth string) string {
+	sum := sha256.Sum256([]byte(keyAuth))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func recordKey(fqdn, value string) string {
+	return fqdn + "|" + value
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid value %q for %s, using default %d", v, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid value %q for %s, using default %s", v, key, fallback)
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// runDNSChallenge implements the `dnschallenge` subcommand, following the
+// same present/cleanup calling convention as lego's exec/manual DNS
+// providers: `dnschallenge present <domain> <token> <key_auth>` and
+// `dnschallenge cleanup <domain> <token> <key_auth>`.
+func runDNSChallenge(args []string) {
+	if len(args) != 4 {
+		log.Fatalf("usage: %s dnschallenge <present|cleanup> <domain> <token> <key_auth>", os.Args[0])
+	}
+	action, domain, token, keyAuth := args[0], args[1], args[2], args[3]
+
+	provider, err := newDNSProvider()
+	if err != nil {
+		log.Fatalf("dnschallenge: %v", err)
+	}
+
+	switch action {
+	case "present":
+		if err := provider.Present(domain, token, keyAuth); err != nil {
+			log.Fatalf("dnschallenge: %v", err)
+		}
+	case "cleanup":
+		if err := provider.CleanUp(domain, token, keyAuth); err != nil {
+			log.Fatalf("dnschallenge: %v", err)
+		}
+	default:
+		log.Fatalf("dnschallenge: unknown action %q (want present or cleanup)", action)
+	}
+}
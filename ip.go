@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// fetchPublicIPv4 resolves the caller's current public IPv4 address.
+func fetchPublicIPv4() (string, error) {
+	resp, err := http.Get("http://ipinfo.io/ip")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current IP: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IP response: %w", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// fetchPublicIPv6 resolves the caller's current public IPv6 address. It
+// fails on dual-stack or IPv4-only connections with no route to the
+// IPv6-only lookup service, which callers should treat as "no IPv6
+// available" rather than a fatal error.
+func fetchPublicIPv6() (string, error) {
+	resp, err := http.Get("https://api6.ipify.org")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current IPv6 address: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IPv6 response: %w", err)
+	}
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("unexpected response from IPv6 lookup service: %q", ip)
+	}
+	return ip, nil
+}
+
+// interfaceIPv4 returns the first IPv4 address assigned to the named
+// network interface, for the `interface:<name>` content source.
+func interfaceIPv4(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("interface %q not found: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("reading addresses for interface %q: %w", name, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			return ip4.String(), nil
+		}
+	}
+	return "", fmt.Errorf("interface %q has no IPv4 address", name)
+}
+
+// interfaceIPv6 returns the first global unicast IPv6 address assigned to
+// the named network interface, for the `interface:<name>` content source
+// on an AAAA record. Link-local addresses are skipped since they aren't
+// routable and would make for a useless DNS record.
+func interfaceIPv6(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("interface %q not found: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("reading addresses for interface %q: %w", name, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if ip.To4() != nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("interface %q has no global IPv6 address", name)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const kubernetesResyncPeriod = 30 * time.Second
+
+// kubernetesOptions configures --kubernetes mode: which record to keep in
+// sync and where to source the IPs from. useInternalIP only applies to
+// --service: instead of the Service's LoadBalancer ingress, it publishes the
+// InternalIP of every cluster Node (matching kubernetes-cloudflare-sync's
+// use-internal-ip), for bare-metal clusters where clients reach a
+// NodePort/hostNetwork Service directly on a routable Node address rather
+// than through a cloud load balancer.
+type kubernetesOptions struct {
+	dnsName       string
+	useInternalIP bool
+	namespace     string
+	serviceName   string
+	ingressName   string
+}
+
+// runKubernetesMode watches a Service (LoadBalancer) or Ingress and
+// reconciles the configured DNS record to its current external IPs,
+// instead of sourcing the IP from ipinfo.io. It runs until the process is
+// killed.
+func runKubernetesMode(opts kubernetesOptions) {
+	clientset, err := newKubernetesClient()
+	if err != nil {
+		log.Fatalf("kubernetes: failed to build client: %v", err)
+	}
+
+	api, err := newCloudflareClient(nil)
+	if err != nil {
+		log.Fatalf("kubernetes: failed to create Cloudflare API client: %v", err)
+	}
+
+	r := &kubernetesReconciler{api: api, opts: opts, nodeIPs: make(map[string]string)}
+
+	// Node informers are cluster-scoped (not namespaced), so they need their
+	// own factory even though opts.namespace already restricts the
+	// Service/Ingress factory below.
+	clusterFactory := informers.NewSharedInformerFactory(clientset, kubernetesResyncPeriod)
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, kubernetesResyncPeriod,
+		informers.WithNamespace(opts.namespace))
+
+	if opts.serviceName != "" {
+		informer := factory.Core().V1().Services().Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { r.reconcileService(obj) },
+			UpdateFunc: func(_, obj interface{}) { r.reconcileService(obj) },
+			DeleteFunc: func(obj interface{}) { r.deleteService(obj) },
+		})
+	}
+	if opts.ingressName != "" {
+		informer := factory.Networking().V1().Ingresses().Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { r.reconcileIngress(obj) },
+			UpdateFunc: func(_, obj interface{}) { r.reconcileIngress(obj) },
+			DeleteFunc: func(obj interface{}) { r.deleteIngress(obj) },
+		})
+	}
+	if opts.useInternalIP {
+		informer := clusterFactory.Core().V1().Nodes().Informer()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { r.setNode(obj) },
+			UpdateFunc: func(_, obj interface{}) { r.setNode(obj) },
+			DeleteFunc: func(obj interface{}) { r.removeNode(obj) },
+		})
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+	if opts.useInternalIP {
+		clusterFactory.Start(stop)
+		clusterFactory.WaitForCacheSync(stop)
+	}
+
+	log.Printf("kubernetes: watching for changes to %s (namespace=%s)", opts.dnsName, opts.namespace)
+	select {}
+}
+
+// newKubernetesClient builds a client-go clientset, preferring in-cluster
+// config and falling back to KUBECONFIG / the default kubeconfig path so
+// the same binary works both inside and outside a cluster.
+func newKubernetesClient() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := os.Getenv("KUBECONFIG")
+		if kubeconfig == "" {
+			kubeconfig = os.Getenv("HOME") + "/.kube/config"
+		}
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("no in-cluster config and failed to load kubeconfig %s: %w", kubeconfig, err)
+		}
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+type kubernetesReconciler struct {
+	api  *cloudflare.API
+	opts kubernetesOptions
+
+	mu          sync.Mutex
+	nodeIPs     map[string]string // node name -> InternalIP, only used when opts.useInternalIP
+	lastService *corev1.Service   // most recent Service seen, re-converged when the node set changes
+}
+
+func (r *kubernetesReconciler) reconcileService(obj interface{}) {
+	svc, ok := obj.(*corev1.Service)
+	if !ok || svc.Name != r.opts.serviceName {
+		return
+	}
+
+	r.mu.Lock()
+	r.lastService = svc
+	r.mu.Unlock()
+
+	var ips []string
+	if r.opts.useInternalIP {
+		ips = r.currentNodeIPs()
+	} else {
+		for _, ing := range svc.Status.LoadBalancer.Ingress {
+			ips = append(ips, resolveLoadBalancerIngress(ing)...)
+		}
+	}
+
+	if err := r.converge(ips); err != nil {
+		log.Printf("kubernetes: failed to reconcile service %s/%s: %v", svc.Namespace, svc.Name, err)
+	}
+}
+
+// deleteService removes every record for opts.dnsName once the backing
+// Service is gone, rather than leaving it pointed at IPs that no longer
+// serve anything.
+func (r *kubernetesReconciler) deleteService(obj interface{}) {
+	svc, ok := r.deletedObject(obj).(*corev1.Service)
+	if !ok || svc.Name != r.opts.serviceName {
+		return
+	}
+
+	r.mu.Lock()
+	r.lastService = nil
+	r.mu.Unlock()
+
+	if err := r.deleteAllRecords(); err != nil {
+		log.Printf("kubernetes: failed to clean up records for deleted service %s/%s: %v", svc.Namespace, svc.Name, err)
+	}
+}
+
+func (r *kubernetesReconciler) reconcileIngress(obj interface{}) {
+	ing, ok := obj.(*networkingv1.Ingress)
+	if !ok || ing.Name != r.opts.ingressName {
+		return
+	}
+
+	var ips []string
+	for _, lbIng := range ing.Status.LoadBalancer.Ingress {
+		ips = append(ips, resolveLoadBalancerIngress(lbIng)...)
+	}
+
+	if err := r.converge(ips); err != nil {
+		log.Printf("kubernetes: failed to reconcile ingress %s/%s: %v", ing.Namespace, ing.Name, err)
+	}
+}
+
+// deleteIngress removes every record for opts.dnsName once the backing
+// Ingress is gone.
+func (r *kubernetesReconciler) deleteIngress(obj interface{}) {
+	ing, ok := r.deletedObject(obj).(*networkingv1.Ingress)
+	if !ok || ing.Name != r.opts.ingressName {
+		return
+	}
+
+	if err := r.deleteAllRecords(); err != nil {
+		log.Printf("kubernetes: failed to clean up records for deleted ingress %s/%s: %v", ing.Namespace, ing.Name, err)
+	}
+}
+
+// deletedObject unwraps the cache.DeletedFinalStateUnknown tombstone a
+// DeleteFunc handler receives when the informer missed the actual delete
+// event, returning the last known object either way.
+func (r *kubernetesReconciler) deletedObject(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// resolveLoadBalancerIngress extracts the usable IPv4 addresses from a
+// single LoadBalancerIngress entry. GCP, Azure and bare-metal (MetalLB)
+// populate IP directly; AWS's ELB/NLB instead populate Hostname, which is
+// resolved via DNS to the addresses it currently points at so those
+// providers don't silently end up with no IPs at all.
+func resolveLoadBalancerIngress(ing corev1.LoadBalancerIngress) []string {
+	if ing.IP != "" {
+		if parsed := net.ParseIP(ing.IP); parsed != nil && parsed.To4() != nil {
+			return []string{ing.IP}
+		}
+		return nil
+	}
+	if ing.Hostname == "" {
+		return nil
+	}
+
+	addrs, err := net.LookupHost(ing.Hostname)
+	if err != nil {
+		log.Printf("kubernetes: failed to resolve load balancer hostname %q: %v", ing.Hostname, err)
+		return nil
+	}
+	var ips []string
+	for _, addr := range addrs {
+		if parsed := net.ParseIP(addr); parsed != nil && parsed.To4() != nil {
+			ips = append(ips, addr)
+		}
+	}
+	return ips
+}
+
+// setNode records or updates a Node's InternalIP and re-converges the
+// tracked Service, since --use-internal-ip's desired IP set depends on
+// cluster membership rather than anything on the Service object itself.
+func (r *kubernetesReconciler) setNode(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	ip := nodeInternalIP(node)
+	if ip == "" {
+		return
+	}
+
+	r.mu.Lock()
+	r.nodeIPs[node.Name] = ip
+	r.mu.Unlock()
+
+	r.reconcileNodeChange()
+}
+
+func (r *kubernetesReconciler) removeNode(obj interface{}) {
+	node, ok := r.deletedObject(obj).(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.nodeIPs, node.Name)
+	r.mu.Unlock()
+
+	r.reconcileNodeChange()
+}
+
+func (r *kubernetesReconciler) currentNodeIPs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ips := make([]string, 0, len(r.nodeIPs))
+	for _, ip := range r.nodeIPs {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func (r *kubernetesReconciler) reconcileNodeChange() {
+	r.mu.Lock()
+	svc := r.lastService
+	r.mu.Unlock()
+	if svc == nil {
+		return
+	}
+
+	if err := r.converge(r.currentNodeIPs()); err != nil {
+		log.Printf("kubernetes: failed to reconcile %s/%s after node change: %v", svc.Namespace, svc.Name, err)
+	}
+}
+
+// nodeInternalIP returns node's InternalIP address, the routable LAN/public
+// address kubernetes-cloudflare-sync's use-internal-ip publishes, as
+// opposed to a Service's ClusterIP which is only reachable from inside the
+// cluster.
+func nodeInternalIP(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// converge creates and deletes A records for r.opts.dnsName so the set
+// of records matches ips exactly, supporting multiple IPs per record name
+// (e.g. a Service backed by several load balancer nodes).
+func (r *kubernetesReconciler) converge(ips []string) error {
+	if len(ips) == 0 {
+		log.Printf("kubernetes: no external IPs yet for %s, skipping reconcile", r.opts.dnsName)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHTTPTimeout)
+	defer cancel()
+
+	zoneID, err := zoneIDForName(r.api, r.opts.dnsName)
+	if err != nil {
+		return err
+	}
+	rc := &cloudflare.ResourceContainer{Identifier: zoneID, Type: "zone"}
+
+	existing, _, err := r.api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: "A", Name: r.opts.dnsName})
+	if err != nil {
+		return fmt.Errorf("listing existing records: %w", err)
+	}
+
+	want := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		want[ip] = true
+	}
+
+	have := make(map[string]cloudflare.DNSRecord, len(existing))
+	for _, rec := range existing {
+		have[rec.Content] = rec
+	}
+
+	for ip := range want {
+		if _, ok := have[ip]; ok {
+			continue
+		}
+		log.Printf("kubernetes: creating A record %s -> %s", r.opts.dnsName, ip)
+		if _, err := r.api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
+			Type:    "A",
+			Name:    r.opts.dnsName,
+			Content: ip,
+			TTL:     1,
+		}); err != nil {
+			return fmt.Errorf("creating record for %s: %w", ip, err)
+		}
+	}
+
+	for ip, rec := range have {
+		if want[ip] {
+			continue
+		}
+		log.Printf("kubernetes: deleting stale A record %s -> %s", r.opts.dnsName, ip)
+		if err := r.api.DeleteDNSRecord(ctx, rc, rec.ID); err != nil {
+			return fmt.Errorf("deleting record for %s: %w", ip, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteAllRecords removes every existing record for r.opts.dnsName,
+// regardless of content, used when the Service/Ingress backing it is
+// deleted so stale records don't linger in Cloudflare.
+func (r *kubernetesReconciler) deleteAllRecords() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHTTPTimeout)
+	defer cancel()
+
+	zoneID, err := zoneIDForName(r.api, r.opts.dnsName)
+	if err != nil {
+		return err
+	}
+	rc := &cloudflare.ResourceContainer{Identifier: zoneID, Type: "zone"}
+
+	existing, _, err := r.api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Name: r.opts.dnsName})
+	if err != nil {
+		return fmt.Errorf("listing existing records: %w", err)
+	}
+	for _, rec := range existing {
+		log.Printf("kubernetes: deleting %s record %s -> %s (backing resource removed)", rec.Type, r.opts.dnsName, rec.Content)
+		if err := r.api.DeleteDNSRecord(ctx, rc, rec.ID); err != nil {
+			return fmt.Errorf("deleting record %s: %w", rec.ID, err)
+		}
+	}
+	return nil
+}
+
+// parseKubernetesOptions builds kubernetesOptions from the --dns-name,
+// --use-internal-ip, --namespace, --service and --ingress flags.
+func parseKubernetesOptions(dnsName string, useInternalIP bool, namespace, service, ingress string) kubernetesOptions {
+	if namespace == "" {
+		namespace = "default"
+	}
+	return kubernetesOptions{
+		dnsName:       strings.TrimSuffix(dnsName, "."),
+		useInternalIP: useInternalIP,
+		namespace:     namespace,
+		serviceName:   service,
+		ingressName:   ingress,
+	}
+}
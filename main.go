@@ -2,11 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -14,36 +13,55 @@ import (
 	"github.com/cloudflare/cloudflare-go"
 )
 
-// getEnv fetches a required environment variable and logs a fatal error if not found.
-func getEnv(key string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		log.Fatalf("Environment variable %q not set", key)
+// firstEnv returns the value of the first set environment variable among keys, or "".
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
 	}
-	return val
+	return ""
 }
 
-func sendTelegramMessage(message string) {
-	// Fetch Telegram credentials from environment variables.
-	telegramBotToken := getEnv("TELEGRAM_BOT_TOKEN")
-	telegramChatID := getEnv("TELEGRAM_CHAT_ID")
+// newCloudflareClient builds a Cloudflare API client, preferring a scoped API
+// Token (CLOUDFLARE_API_TOKEN / CF_API_TOKEN) over the legacy Global API Key +
+// email combination (CLOUDFLARE_API_KEY + CLOUDFLARE_EMAIL). Using a token
+// lets operators grant least-privilege access (e.g. "Zone.DNS Edit" for a
+// single zone) instead of handing the tool full account credentials.
+// httpClient may be nil to use cloudflare-go's default transport, or a
+// custom client (e.g. a retrying one for daemon mode).
+func newCloudflareClient(httpClient *http.Client) (*cloudflare.API, error) {
+	var opts []cloudflare.Option
+	if httpClient != nil {
+		opts = append(opts, cloudflare.HTTPClient(httpClient))
+	}
 
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", telegramBotToken)
-	data := url.Values{}
-	data.Set("chat_id", telegramChatID)
-	data.Set("text", message)
+	if token := firstEnv("CLOUDFLARE_API_TOKEN", "CF_API_TOKEN"); token != "" {
+		return cloudflare.NewWithAPIToken(token, opts...)
+	}
 
-	resp, err := http.PostForm(apiURL, data)
-	if err != nil {
-		log.Printf("Error sending telegram message: %v", err)
-		return
+	var missing []string
+	email := os.Getenv("CLOUDFLARE_EMAIL")
+	if email == "" {
+		missing = append(missing, "CLOUDFLARE_EMAIL")
+	}
+	apiKey := os.Getenv("CLOUDFLARE_API_KEY")
+	if apiKey == "" {
+		missing = append(missing, "CLOUDFLARE_API_KEY")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no Cloudflare credentials found: set CLOUDFLARE_API_TOKEN (or CF_API_TOKEN) for token auth, or set %s for legacy key auth", strings.Join(missing, ", "))
 	}
-	defer resp.Body.Close()
-	// Optionally, you can check resp.StatusCode or inspect the response body.
+
+	return cloudflare.New(apiKey, email, opts...)
 }
 
-// updateDomain updates the root A record and its wildcard for a single domain.
-func updateDomain(ctx context.Context, api *cloudflare.API, currentIP, domain string) error {
+// updateDomain updates the root and wildcard records for a single domain.
+// It manages the A record whenever currentIPv4 is non-empty and the AAAA
+// record whenever currentIPv6 is non-empty, in the same pass; a failure
+// updating one family is reported but does not prevent the other family
+// from being synced.
+func updateDomain(ctx context.Context, api *cloudflare.API, currentIPv4, currentIPv6, domain string) error {
 	start := time.Now()
 	zoneID, err := api.ZoneIDByName(domain)
 	if err != nil {
@@ -54,73 +72,90 @@ func updateDomain(ctx context.Context, api *cloudflare.API, currentIP, domain st
 	rootName := domain
 	wildcardName := "*." + domain
 
-	// Fetch existing root record
-	rootRecords, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: "A", Name: rootName})
-	if err != nil {
-		return fmt.Errorf("error listing root record for %s: %w", domain, err)
+	var errs []error
+	if currentIPv4 != "" {
+		if err := syncRecord(ctx, api, rc, "A", rootName, currentIPv4, true); err != nil {
+			errs = append(errs, err)
+		}
+		if err := syncRecord(ctx, api, rc, "A", wildcardName, currentIPv4, true); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	var rootRec *cloudflare.DNSRecord
-	if len(rootRecords) > 0 {
-		rootRec = &rootRecords[0]
+	if currentIPv6 != "" {
+		if err := syncRecord(ctx, api, rc, "AAAA", rootName, currentIPv6, false); err != nil {
+			errs = append(errs, err)
+		}
+		if err := syncRecord(ctx, api, rc, "AAAA", wildcardName, currentIPv6, false); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	// Fetch existing wildcard record
-	wildcardRecords, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: "A", Name: wildcardName})
+	fmt.Printf("Finished %s in %s\n", domain, time.Since(start).Truncate(time.Millisecond))
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s) updating %s: %v", len(errs), domain, errs)
+	}
+	return nil
+}
+
+// syncRecord updates a single existing record of recordType/name to
+// currentIP if it has drifted. When requireExisting is false (AAAA, which
+// not every zone has set up yet), a missing record is skipped rather than
+// treated as an error, so dual-stack sync degrades gracefully on
+// IPv4-only zones.
+func syncRecord(ctx context.Context, api *cloudflare.API, rc *cloudflare.ResourceContainer, recordType, name, currentIP string, requireExisting bool) error {
+	records, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: recordType, Name: name})
 	if err != nil {
-		return fmt.Errorf("error listing wildcard record for %s: %w", domain, err)
+		return fmt.Errorf("error listing %s record for %s: %w", recordType, name, err)
 	}
-	var wildcardRec *cloudflare.DNSRecord
-	if len(wildcardRecords) > 0 {
-		wildcardRec = &wildcardRecords[0]
+	if len(records) == 0 {
+		if requireExisting {
+			return fmt.Errorf("%s record for %s not found", recordType, name)
+		}
+		fmt.Printf("%s record for %s not found, skipping\n", recordType, name)
+		return nil
 	}
+	rec := records[0]
 
-	if rootRec == nil {
-		return fmt.Errorf("A record for %s not found", rootName)
+	if rec.Content == currentIP {
+		fmt.Printf("%s already up to date (%s)\n", name, currentIP)
+		return nil
 	}
-	if wildcardRec == nil {
-		return fmt.Errorf("A record for %s not found", wildcardName)
+
+	fmt.Printf("Updating %s from %s to %s\n", name, rec.Content, currentIP)
+	if _, err := api.UpdateDNSRecord(ctx, rc, cloudflare.UpdateDNSRecordParams{
+		ID:      rec.ID,
+		Type:    recordType,
+		Name:    name,
+		Content: currentIP,
+		TTL:     rec.TTL,
+		Proxied: rec.Proxied,
+	}); err != nil {
+		return fmt.Errorf("failed updating %s: %w", name, err)
 	}
+	notify("record_updated", fmt.Sprintf("IP for %s updated to %s", name, currentIP))
+	return nil
+}
 
-	// Update root record if needed
-	if rootRec.Content == currentIP {
-		fmt.Printf("%s already up to date (%s)\n", rootName, currentIP)
-	} else {
-		fmt.Printf("Updating %s from %s to %s\n", rootName, rootRec.Content, currentIP)
-		_, err = api.UpdateDNSRecord(ctx, rc, cloudflare.UpdateDNSRecordParams{
-			ID:      rootRec.ID,
-			Type:    "A",
-			Name:    rootName,
-			Content: currentIP,
-			TTL:     rootRec.TTL,
-			Proxied: rootRec.Proxied,
-		})
-		if err != nil {
-			return fmt.Errorf("failed updating %s: %w", rootName, err)
-		}
-		sendTelegramMessage(fmt.Sprintf("IP for %s updated to %s", rootName, currentIP))
-	}
-
-	// Update wildcard if needed
-	if wildcardRec.Content == currentIP {
-		fmt.Printf("%s already up to date (%s)\n", wildcardName, currentIP)
-	} else {
-		fmt.Printf("Updating %s from %s to %s\n", wildcardName, wildcardRec.Content, currentIP)
-		_, err = api.UpdateDNSRecord(ctx, rc, cloudflare.UpdateDNSRecordParams{
-			ID:      wildcardRec.ID,
-			Type:    "A",
-			Name:    wildcardName,
-			Content: currentIP,
-			TTL:     wildcardRec.TTL,
-			Proxied: wildcardRec.Proxied,
-		})
-		if err != nil {
-			return fmt.Errorf("failed updating %s: %w", wildcardName, err)
-		}
-		sendTelegramMessage(fmt.Sprintf("IP for %s updated to %s", wildcardName, currentIP))
+// ipFamilies reports which address families to sync, controlled by the
+// IPV4_ONLY, IPV6_ONLY and DUAL_STACK env toggles. With none set, both
+// families are attempted and a failure to determine either family's
+// address is tolerated as long as the other succeeds (see main).
+func ipFamilies() (wantIPv4, wantIPv6 bool) {
+	switch {
+	case isEnvTrue("IPV4_ONLY"):
+		return true, false
+	case isEnvTrue("IPV6_ONLY"):
+		return false, true
+	case isEnvTrue("DUAL_STACK"):
+		return true, true
+	default:
+		return true, true
 	}
+}
 
-	fmt.Printf("Finished %s in %s\n", domain, time.Since(start).Truncate(time.Millisecond))
-	return nil
+func isEnvTrue(key string) bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	return v == "1" || v == "true" || v == "yes"
 }
 
 func parseDomains() []string {
@@ -151,23 +186,81 @@ func parseDomains() []string {
 }
 
 func main() {
-	// 1. Determine current public IP.
-	resp, err := http.Get("http://ipinfo.io/ip")
-	if err != nil {
-		log.Fatalf("Failed to get current IP: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "dnschallenge" {
+		runDNSChallenge(os.Args[2:])
+		return
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Failed to read IP response: %v", err)
+
+	kubernetesMode := flag.Bool("kubernetes", false, "watch a Kubernetes Service/Ingress and sync its external IPs instead of resolving the public IP")
+	dnsName := flag.String("dns-name", "", "DNS record name to keep in sync with the Service/Ingress external IPs (required with --kubernetes)")
+	useInternalIP := flag.Bool("use-internal-ip", false, "with --service, publish every Node's InternalIP instead of the Service's LoadBalancer ingress IPs")
+	namespace := flag.String("namespace", "default", "namespace of the Service/Ingress to watch")
+	serviceName := flag.String("service", "", "name of the Service to watch")
+	ingressName := flag.String("ingress", "", "name of the Ingress to watch")
+	configPath := flag.String("config", "", "path to a records.yaml/json file declaring the records to manage, instead of the built-in A + wildcard defaults")
+	interval := flag.Duration("interval", 0, "run as a long-lived daemon, checking the public IP on this interval (e.g. 5m) instead of exiting after one check")
+	cacheFile := flag.String("cache-file", defaultIPCachePath(), "path to cache the last-seen IP(s) across daemon restarts")
+	flag.Parse()
+
+	if *kubernetesMode {
+		if *dnsName == "" || (*serviceName == "" && *ingressName == "") {
+			log.Fatal("--kubernetes requires --dns-name and one of --service/--ingress")
+		}
+		runKubernetesMode(parseKubernetesOptions(*dnsName, *useInternalIP, *namespace, *serviceName, *ingressName))
+		return
+	}
+
+	if *interval > 0 {
+		runDaemon(*interval, *cacheFile)
+		return
+	}
+
+	if *configPath != "" {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		api, err := newCloudflareClient(nil)
+		if err != nil {
+			log.Fatalf("Failed to create Cloudflare API client: %v", err)
+		}
+		if err := reconcileRecords(context.Background(), api, cfg.Records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// 1. Determine current public IP(s).
+	wantIPv4, wantIPv6 := ipFamilies()
+	var currentIPv4, currentIPv6 string
+	if wantIPv4 {
+		ip, err := fetchPublicIPv4()
+		if err != nil && !wantIPv6 {
+			log.Fatalf("%v", err)
+		} else if err != nil {
+			log.Printf("Could not determine public IPv4 address: %v", err)
+		} else {
+			currentIPv4 = ip
+			fmt.Printf("Current IPv4: %s\n", currentIPv4)
+		}
+	}
+	if wantIPv6 {
+		ip, err := fetchPublicIPv6()
+		if err != nil && !wantIPv4 {
+			log.Fatalf("%v", err)
+		} else if err != nil {
+			log.Printf("Could not determine public IPv6 address: %v", err)
+		} else {
+			currentIPv6 = ip
+			fmt.Printf("Current IPv6: %s\n", currentIPv6)
+		}
+	}
+	if currentIPv4 == "" && currentIPv6 == "" {
+		log.Fatal("Could not determine a public IPv4 or IPv6 address")
 	}
-	currentIP := strings.TrimSpace(string(body))
-	fmt.Printf("Current IP: %s\n", currentIP)
 
 	// 2. Cloudflare credentials
-	cloudflareEmail := getEnv("CLOUDFLARE_EMAIL")
-	cloudflareAPIKey := getEnv("CLOUDFLARE_API_KEY")
-	api, err := cloudflare.New(cloudflareAPIKey, cloudflareEmail)
+	api, err := newCloudflareClient(nil)
 	if err != nil {
 		log.Fatalf("Failed to create Cloudflare API client: %v", err)
 	}
@@ -179,10 +272,10 @@ func main() {
 
 	var hadError bool
 	for _, domain := range domains {
-		if err := updateDomain(ctx, api, currentIP, domain); err != nil {
+		if err := updateDomain(ctx, api, currentIPv4, currentIPv6, domain); err != nil {
 			hadError = true
 			log.Printf("Error updating %s: %v", domain, err)
-			sendTelegramMessage(fmt.Sprintf("Error updating %s: %v", domain, err))
+			notify("error", fmt.Sprintf("Error updating %s: %v", domain, err))
 		}
 	}
 	if hadError {
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notifyTimeout bounds how long any single notifier gets to deliver an
+// event, so a slow or unreachable webhook can't stall the main flow.
+const notifyTimeout = 10 * time.Second
+
+// Event describes something the tool wants to tell the outside world
+// about. Notifiers format it however suits their channel.
+type Event struct {
+	Type    string // "ip_changed", "record_updated", or "error"
+	Message string
+	Time    time.Time
+}
+
+// Notifier delivers an Event to one external channel.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event Event) error
+}
+
+// notifierEntry pairs a configured Notifier with the set of event types it
+// should receive. A nil/empty events set means "every event type".
+type notifierEntry struct {
+	notifier Notifier
+	events   map[string]bool
+}
+
+func (e notifierEntry) wants(eventType string) bool {
+	return len(e.events) == 0 || e.events[eventType]
+}
+
+var (
+	notifiersOnce  sync.Once
+	activeNotifier []notifierEntry
+)
+
+// notify builds an Event and fans it out to every channel configured via
+// NOTIFIERS that opted into this event type, concurrently and with a
+// bounded per-notifier timeout. A notifier failing to deliver is logged
+// but never blocks the others or the caller beyond notifyTimeout.
+func notify(eventType, message string) {
+	notifiersOnce.Do(func() { activeNotifier = newNotifiers() })
+	if len(activeNotifier) == 0 {
+		return
+	}
+
+	event := Event{Type: eventType, Message: message, Time: time.Now()}
+
+	var wg sync.WaitGroup
+	for _, entry := range activeNotifier {
+		if !entry.wants(eventType) {
+			continue
+		}
+		wg.Add(1)
+		go func(n Notifier) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := n.Notify(ctx, event); err != nil {
+				log.Printf("notify: %s failed: %v", n.Name(), err)
+			}
+		}(entry.notifier)
+	}
+	wg.Wait()
+}
+
+// newNotifiers builds the set of Notifiers named in the NOTIFIERS env var
+// (comma/space separated). Each entry is "name" (receives every event) or
+// "name:type1+type2" to restrict it to specific event types, e.g.:
+//
+//	NOTIFIERS="webhook:error,discord:ip_changed+record_updated"
+//
+// routes errors to a PagerDuty-style webhook and successes to Discord. A
+// named channel missing its required env vars is logged and skipped
+// rather than failing the whole tool.
+//
+// If NOTIFIERS is unset but TELEGRAM_BOT_TOKEN/TELEGRAM_CHAT_ID are set, it
+// defaults to "telegram" so a deployment configured before NOTIFIERS existed
+// keeps getting alerts instead of silently going quiet on upgrade.
+func newNotifiers() []notifierEntry {
+	raw := os.Getenv("NOTIFIERS")
+	if raw == "" && os.Getenv("TELEGRAM_BOT_TOKEN") != "" && os.Getenv("TELEGRAM_CHAT_ID") != "" {
+		raw = "telegram"
+	}
+
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+
+	var entries []notifierEntry
+	for _, field := range fields {
+		name, filter, _ := strings.Cut(strings.TrimSpace(field), ":")
+
+		n, err := buildNotifier(strings.ToLower(name))
+		if err != nil {
+			log.Printf("notify: skipping %q: %v", name, err)
+			continue
+		}
+
+		var events map[string]bool
+		if filter != "" {
+			events = make(map[string]bool)
+			for _, eventType := range strings.Split(filter, "+") {
+				events[strings.TrimSpace(eventType)] = true
+			}
+		}
+
+		entries = append(entries, notifierEntry{notifier: n, events: events})
+	}
+	return entries
+}
+
+func buildNotifier(name string) (Notifier, error) {
+	switch name {
+	case "telegram":
+		return newTelegramNotifier()
+	case "discord":
+		return newWebhookNotifier("discord", "DISCORD_WEBHOOK_URL", discordPayload)
+	case "slack":
+		return newWebhookNotifier("slack", "SLACK_WEBHOOK_URL", slackPayload)
+	case "webhook":
+		return newWebhookNotifier("webhook", "WEBHOOK_URL", genericPayload)
+	case "email":
+		return newEmailNotifier()
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", name)
+	}
+}
+
+// telegramNotifier posts a message via the Telegram Bot API.
+type telegramNotifier struct {
+	botToken string
+	chatID   string
+}
+
+func newTelegramNotifier() (*telegramNotifier, error) {
+	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+	chatID := os.Getenv("TELEGRAM_CHAT_ID")
+	if botToken == "" || chatID == "" {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must both be set")
+	}
+	return &telegramNotifier{botToken: botToken, chatID: chatID}, nil
+}
+
+func (t *telegramNotifier) Name() string { return "telegram" }
+
+func (t *telegramNotifier) Notify(ctx context.Context, event Event) error {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.botToken)
+	data := url.Values{}
+	data.Set("chat_id", t.chatID)
+	data.Set("text", event.Message)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("telegram API returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// webhookNotifier POSTs a JSON payload built by payloadFn to a webhook URL
+// read from envVar. It backs the Discord, Slack and generic-webhook
+// channels, which only differ in their expected JSON shape.
+type webhookNotifier struct {
+	name      string
+	url       string
+	payloadFn func(Event) interface{}
+}
+
+func newWebhookNotifier(name, envVar string, payloadFn func(Event) interface{}) (*webhookNotifier, error) {
+	webhookURL := os.Getenv(envVar)
+	if webhookURL == "" {
+		return nil, fmt.Errorf("%s must be set", envVar)
+	}
+	return &webhookNotifier{name: name, url: webhookURL, payloadFn: payloadFn}, nil
+}
+
+func (w *webhookNotifier) Name() string { return w.name }
+
+func (w *webhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(w.payloadFn(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func discordPayload(event Event) interface{} {
+	return map[string]string{"content": fmt.Sprintf("[%s] %s", event.Type, event.Message)}
+}
+
+func slackPayload(event Event) interface{} {
+	return map[string]string{"text": fmt.Sprintf("[%s] %s", event.Type, event.Message)}
+}
+
+func genericPayload(event Event) interface{} {
+	return map[string]string{
+		"type":    event.Type,
+		"message": event.Message,
+		"time":    event.Time.Format(time.RFC3339),
+	}
+}
+
+// emailNotifier sends an event as a plain-text email over SMTP.
+type emailNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func newEmailNotifier() (*emailNotifier, error) {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	from := os.Getenv("SMTP_FROM")
+	to := os.Getenv("SMTP_TO")
+	if host == "" || port == "" || from == "" || to == "" {
+		return nil, fmt.Errorf("SMTP_HOST, SMTP_PORT, SMTP_FROM and SMTP_TO must all be set")
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return &emailNotifier{
+		addr: host + ":" + port,
+		auth: auth,
+		from: from,
+		to:   strings.Split(to, ","),
+	}, nil
+}
+
+func (e *emailNotifier) Name() string { return "email" }
+
+// Notify sends the event over SMTP, which net/smtp has no native way to
+// bound by context, so SendMail runs in a goroutine raced against ctx to
+// enforce the caller's deadline. The goroutine is left to finish (or fail)
+// on its own if ctx wins the race, since net/smtp gives no way to cancel it.
+func (e *emailNotifier) Notify(ctx context.Context, event Event) error {
+	subject := fmt.Sprintf("cloudflare-ddns-renewal: %s", event.Type)
+	body := fmt.Sprintf("Subject: %s\r\n\r\n%s\r\n", subject, event.Message)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.addr, e.auth, e.from, e.to, []byte(body))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("sending email timed out: %w", ctx.Err())
+	}
+}
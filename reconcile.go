@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// reconcileRecords applies a declarative set of RecordSpecs: it resolves
+// each spec's desired content, looks up the zone that owns it (one spec
+// at a time), diffs desired vs. actual Cloudflare records, and issues the necessary
+// create/update/delete calls. Errors for individual records are collected
+// rather than aborting the whole run, so one bad entry doesn't block the
+// rest of the config.
+func reconcileRecords(ctx context.Context, api *cloudflare.API, specs []RecordSpec) error {
+	var errs []error
+
+	for _, spec := range specs {
+		content, err := spec.resolveContent()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", spec.Type, spec.Name, err))
+			continue
+		}
+
+		if err := reconcileRecord(ctx, api, spec, content); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", spec.Type, spec.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reconcile %d record(s): %v", len(errs), errs)
+	}
+	return nil
+}
+
+// reconcileRecord converges the single record named by spec towards
+// content: update in place if exactly one matching record exists with
+// different content, TTL or proxied setting, create it if none exists, or
+// update the first (when it differs) and
+// prune any stale duplicates if more than one exists.
+func reconcileRecord(ctx context.Context, api *cloudflare.API, spec RecordSpec, content string) error {
+	zoneID, err := zoneIDForName(api, spec.Name)
+	if err != nil {
+		return err
+	}
+	rc := &cloudflare.ResourceContainer{Identifier: zoneID, Type: "zone"}
+
+	existing, _, err := api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{Type: spec.Type, Name: spec.Name})
+	if err != nil {
+		return fmt.Errorf("listing existing records: %w", err)
+	}
+
+	if len(existing) == 0 {
+		fmt.Printf("Creating %s record %s -> %s\n", spec.Type, spec.Name, content)
+		_, err := api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
+			Type:    spec.Type,
+			Name:    spec.Name,
+			Content: content,
+			TTL:     spec.TTL,
+			Proxied: &spec.Proxied,
+		})
+		return err
+	}
+
+	primary := existing[0]
+	proxied := boolValue(primary.Proxied)
+	// Cloudflare forces the stored TTL to 1 ("automatic") for proxied
+	// records regardless of what was requested, so comparing TTL for a
+	// proxied record would diff against spec.TTL forever and re-update on
+	// every run.
+	ttlChanged := !proxied && primary.TTL != spec.TTL
+	if primary.Content != content || ttlChanged || proxied != spec.Proxied {
+		fmt.Printf("Updating %s record %s -> %s\n", spec.Type, spec.Name, content)
+		if _, err := api.UpdateDNSRecord(ctx, rc, cloudflare.UpdateDNSRecordParams{
+			ID:      primary.ID,
+			Type:    spec.Type,
+			Name:    spec.Name,
+			Content: content,
+			TTL:     spec.TTL,
+			Proxied: &spec.Proxied,
+		}); err != nil {
+			return fmt.Errorf("updating record: %w", err)
+		}
+	} else {
+		fmt.Printf("%s record %s already up to date (%s)\n", spec.Type, spec.Name, content)
+	}
+
+	for _, dup := range existing[1:] {
+		fmt.Printf("Deleting stale duplicate %s record %s (%s)\n", spec.Type, spec.Name, dup.Content)
+		if err := api.DeleteDNSRecord(ctx, rc, dup.ID); err != nil {
+			return fmt.Errorf("deleting duplicate record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func boolValue(b *bool) bool {
+	if b == nil {
+		return false
+	}
+	return *b
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// zoneIDForName finds the Cloudflare zone owning name by walking up the
+// label chain (name, then its parent, and so on), so multi-label TLDs like
+// ".co.uk" and arbitrarily nested subdomains both resolve to the zone that
+// actually manages them.
+func zoneIDForName(api *cloudflare.API, name string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if zoneID, err := api.ZoneIDByName(candidate); err == nil {
+			return zoneID, nil
+		}
+	}
+	return "", fmt.Errorf("no zone found for %q or any of its parent domains", name)
+}